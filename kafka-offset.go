@@ -9,10 +9,11 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/ryarnyah/kafka-offset/pkg/metrics"
+	_ "github.com/ryarnyah/kafka-offset/pkg/sinks"
 )
 
 var (
-	sinkName = flag.String("sink", "log", "Sink to use")
+	sinkName = flag.String("sink", "log", "Comma-separated list of sinks to use, e.g. \"kafka,log\"")
 )
 
 func installSignalHandler(stopChs ...chan interface{}) *sync.WaitGroup {
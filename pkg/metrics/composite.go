@@ -0,0 +1,271 @@
+package metrics
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// multiError joins several Close errors from independent sinks into one.
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Filterer optionally restricts which metrics a Sink receives, scoped by
+// topic/consumer-group regular expressions configured on the sink itself.
+// Sinks that don't implement it receive every metric.
+type Filterer interface {
+	IncludeTopic(topic string) bool
+	IncludeGroup(group string) bool
+}
+
+// MatchFilter reports whether value passes an include/exclude regex pair.
+// A nil exclude never rejects, a nil include never restricts.
+func MatchFilter(include, exclude *regexp.Regexp, value string) bool {
+	if exclude != nil && exclude.MatchString(value) {
+		return false
+	}
+	if include != nil && !include.MatchString(value) {
+		return false
+	}
+	return true
+}
+
+// compositeSink fans metric batches out to every configured sink concurrently.
+// A failure (including a panic from a sink whose channels were already
+// closed) is logged and never blocks or crashes the other sinks.
+type compositeSink struct {
+	sinks []Sink
+
+	offsetChan    chan []KafkaOffsetMetric
+	groupChan     chan []KafkaConsumerGroupOffsetMetric
+	topicRateChan chan []KafkaTopicRateMetric
+	groupRateChan chan []KafkaConsumerGroupRateMetric
+	stopCh        chan interface{}
+
+	wg sync.WaitGroup
+}
+
+// newCompositeSink builds a Sink that forwards every batch it receives to each of sinks.
+func newCompositeSink(sinks []Sink) Sink {
+	c := &compositeSink{
+		sinks: sinks,
+
+		offsetChan:    make(chan []KafkaOffsetMetric, 1024),
+		groupChan:     make(chan []KafkaConsumerGroupOffsetMetric, 1024),
+		topicRateChan: make(chan []KafkaTopicRateMetric, 1024),
+		groupRateChan: make(chan []KafkaConsumerGroupRateMetric, 1024),
+		stopCh:        make(chan interface{}),
+	}
+	c.run()
+	return c
+}
+
+func (c *compositeSink) SendOffsetMetrics() chan<- []KafkaOffsetMetric {
+	return c.offsetChan
+}
+
+func (c *compositeSink) SendConsumerGroupOffsetMetrics() chan<- []KafkaConsumerGroupOffsetMetric {
+	return c.groupChan
+}
+
+func (c *compositeSink) SendTopicRateMetrics() chan<- []KafkaTopicRateMetric {
+	return c.topicRateChan
+}
+
+func (c *compositeSink) SendConsumerGroupRateMetrics() chan<- []KafkaConsumerGroupRateMetric {
+	return c.groupRateChan
+}
+
+// dispatch runs fn, recovering from and logging a panic (e.g. a send on a
+// sink whose channel was already closed) instead of letting it take down
+// the other sinks.
+func (c *compositeSink) dispatch(name string, fn func()) {
+	defer c.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.Errorf("sink %s: %v", name, r)
+		}
+	}()
+	fn()
+}
+
+func (c *compositeSink) run() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			select {
+			case batch := <-c.groupChan:
+				for _, sub := range c.sinks {
+					filtered := filterGroupMetrics(sub, batch)
+					if len(filtered) == 0 {
+						continue
+					}
+					c.wg.Add(1)
+					go c.dispatch("consumer group offsets", func() {
+						sub.SendConsumerGroupOffsetMetrics() <- filtered
+					})
+				}
+			case <-c.stopCh:
+				return
+			}
+		}
+	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			select {
+			case batch := <-c.offsetChan:
+				for _, sub := range c.sinks {
+					filtered := filterOffsetMetrics(sub, batch)
+					if len(filtered) == 0 {
+						continue
+					}
+					c.wg.Add(1)
+					go c.dispatch("offsets", func() {
+						sub.SendOffsetMetrics() <- filtered
+					})
+				}
+			case <-c.stopCh:
+				return
+			}
+		}
+	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			select {
+			case batch := <-c.groupRateChan:
+				for _, sub := range c.sinks {
+					filtered := filterGroupRateMetrics(sub, batch)
+					if len(filtered) == 0 {
+						continue
+					}
+					c.wg.Add(1)
+					go c.dispatch("consumer group rates", func() {
+						sub.SendConsumerGroupRateMetrics() <- filtered
+					})
+				}
+			case <-c.stopCh:
+				return
+			}
+		}
+	}()
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			select {
+			case batch := <-c.topicRateChan:
+				for _, sub := range c.sinks {
+					filtered := filterTopicRateMetrics(sub, batch)
+					if len(filtered) == 0 {
+						continue
+					}
+					c.wg.Add(1)
+					go c.dispatch("topic rates", func() {
+						sub.SendTopicRateMetrics() <- filtered
+					})
+				}
+			case <-c.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func filterOffsetMetrics(sink Sink, batch []KafkaOffsetMetric) []KafkaOffsetMetric {
+	filter, ok := sink.(Filterer)
+	if !ok {
+		return batch
+	}
+	out := make([]KafkaOffsetMetric, 0, len(batch))
+	for _, metric := range batch {
+		if filter.IncludeTopic(metric.Topic) {
+			out = append(out, metric)
+		}
+	}
+	return out
+}
+
+func filterGroupMetrics(sink Sink, batch []KafkaConsumerGroupOffsetMetric) []KafkaConsumerGroupOffsetMetric {
+	filter, ok := sink.(Filterer)
+	if !ok {
+		return batch
+	}
+	out := make([]KafkaConsumerGroupOffsetMetric, 0, len(batch))
+	for _, metric := range batch {
+		if filter.IncludeTopic(metric.Topic) && filter.IncludeGroup(metric.Group) {
+			out = append(out, metric)
+		}
+	}
+	return out
+}
+
+func filterTopicRateMetrics(sink Sink, batch []KafkaTopicRateMetric) []KafkaTopicRateMetric {
+	filter, ok := sink.(Filterer)
+	if !ok {
+		return batch
+	}
+	out := make([]KafkaTopicRateMetric, 0, len(batch))
+	for _, metric := range batch {
+		if filter.IncludeTopic(metric.Topic) {
+			out = append(out, metric)
+		}
+	}
+	return out
+}
+
+func filterGroupRateMetrics(sink Sink, batch []KafkaConsumerGroupRateMetric) []KafkaConsumerGroupRateMetric {
+	filter, ok := sink.(Filterer)
+	if !ok {
+		return batch
+	}
+	out := make([]KafkaConsumerGroupRateMetric, 0, len(batch))
+	for _, metric := range batch {
+		if filter.IncludeTopic(metric.Topic) && filter.IncludeGroup(metric.Group) {
+			out = append(out, metric)
+		}
+	}
+	return out
+}
+
+// Close stops the fan-out loops and closes every delegate sink, joining all
+// of their errors together rather than stopping at the first failure.
+func (c *compositeSink) Close() error {
+	close(c.stopCh)
+	c.wg.Wait()
+	close(c.offsetChan)
+	close(c.groupChan)
+	close(c.topicRateChan)
+	close(c.groupRateChan)
+
+	var errs []error
+	for _, sub := range c.sinks {
+		if err := sub.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return multiError(errs)
+	}
+	return nil
+}
+
+// Wait blocks until every delegate sink has flushed everything it owns.
+func (c *compositeSink) Wait() {
+	for _, sub := range c.sinks {
+		sub.Wait()
+	}
+}
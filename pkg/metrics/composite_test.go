@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMatchFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		include string
+		exclude string
+		value   string
+		want    bool
+	}{
+		{name: "no filters", value: "topic-a", want: true},
+		{name: "include matches", include: "^topic-", value: "topic-a", want: true},
+		{name: "include does not match", include: "^topic-", value: "other", want: false},
+		{name: "exclude matches", exclude: "^topic-", value: "topic-a", want: false},
+		{name: "exclude does not match", exclude: "^topic-", value: "other", want: true},
+		{name: "exclude wins over include", include: ".*", exclude: "^topic-", value: "topic-a", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var include, exclude *regexp.Regexp
+			if tt.include != "" {
+				include = regexp.MustCompile(tt.include)
+			}
+			if tt.exclude != "" {
+				exclude = regexp.MustCompile(tt.exclude)
+			}
+			if got := MatchFilter(include, exclude, tt.value); got != tt.want {
+				t.Fatalf("MatchFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeFilterSink is a minimal Sink that also implements Filterer, so it can
+// stand in for KafkaSink when exercising the filter* helpers.
+type fakeFilterSink struct {
+	includeTopic *regexp.Regexp
+	includeGroup *regexp.Regexp
+}
+
+func (f *fakeFilterSink) SendOffsetMetrics() chan<- []KafkaOffsetMetric { return nil }
+func (f *fakeFilterSink) SendConsumerGroupOffsetMetrics() chan<- []KafkaConsumerGroupOffsetMetric {
+	return nil
+}
+func (f *fakeFilterSink) SendTopicRateMetrics() chan<- []KafkaTopicRateMetric      { return nil }
+func (f *fakeFilterSink) SendConsumerGroupRateMetrics() chan<- []KafkaConsumerGroupRateMetric {
+	return nil
+}
+func (f *fakeFilterSink) Close() error { return nil }
+func (f *fakeFilterSink) Wait()        {}
+
+func (f *fakeFilterSink) IncludeTopic(topic string) bool {
+	return MatchFilter(f.includeTopic, nil, topic)
+}
+
+func (f *fakeFilterSink) IncludeGroup(group string) bool {
+	return MatchFilter(f.includeGroup, nil, group)
+}
+
+// fakeSink is a Sink with no Filterer, used to assert the unfiltered passthrough.
+type fakeSink struct{}
+
+func (f *fakeSink) SendOffsetMetrics() chan<- []KafkaOffsetMetric { return nil }
+func (f *fakeSink) SendConsumerGroupOffsetMetrics() chan<- []KafkaConsumerGroupOffsetMetric {
+	return nil
+}
+func (f *fakeSink) SendTopicRateMetrics() chan<- []KafkaTopicRateMetric { return nil }
+func (f *fakeSink) SendConsumerGroupRateMetrics() chan<- []KafkaConsumerGroupRateMetric {
+	return nil
+}
+func (f *fakeSink) Close() error { return nil }
+func (f *fakeSink) Wait()        {}
+
+func TestFilterOffsetMetrics(t *testing.T) {
+	batch := []KafkaOffsetMetric{{Topic: "keep"}, {Topic: "drop"}}
+
+	t.Run("non-filterer sink forwards everything", func(t *testing.T) {
+		got := filterOffsetMetrics(&fakeSink{}, batch)
+		if len(got) != len(batch) {
+			t.Fatalf("got %d metrics, want %d", len(got), len(batch))
+		}
+	})
+
+	t.Run("filterer sink restricts by topic", func(t *testing.T) {
+		sink := &fakeFilterSink{includeTopic: regexp.MustCompile("^keep$")}
+		got := filterOffsetMetrics(sink, batch)
+		if len(got) != 1 || got[0].Topic != "keep" {
+			t.Fatalf("got %+v, want only the keep metric", got)
+		}
+	})
+}
+
+func TestFilterGroupMetrics(t *testing.T) {
+	batch := []KafkaConsumerGroupOffsetMetric{
+		{Topic: "keep", Group: "keep-group"},
+		{Topic: "keep", Group: "drop-group"},
+		{Topic: "drop", Group: "keep-group"},
+	}
+
+	sink := &fakeFilterSink{
+		includeTopic: regexp.MustCompile("^keep$"),
+		includeGroup: regexp.MustCompile("^keep-group$"),
+	}
+	got := filterGroupMetrics(sink, batch)
+	if len(got) != 1 || got[0].Topic != "keep" || got[0].Group != "keep-group" {
+		t.Fatalf("got %+v, want only the keep/keep-group metric", got)
+	}
+}
+
+func TestFilterTopicRateMetrics(t *testing.T) {
+	batch := []KafkaTopicRateMetric{{Topic: "keep"}, {Topic: "drop"}}
+	sink := &fakeFilterSink{includeTopic: regexp.MustCompile("^keep$")}
+	got := filterTopicRateMetrics(sink, batch)
+	if len(got) != 1 || got[0].Topic != "keep" {
+		t.Fatalf("got %+v, want only the keep metric", got)
+	}
+}
+
+func TestFilterGroupRateMetrics(t *testing.T) {
+	batch := []KafkaConsumerGroupRateMetric{
+		{Topic: "keep", Group: "keep-group"},
+		{Topic: "keep", Group: "drop-group"},
+	}
+	sink := &fakeFilterSink{
+		includeTopic: regexp.MustCompile("^keep$"),
+		includeGroup: regexp.MustCompile("^keep-group$"),
+	}
+	got := filterGroupRateMetrics(sink, batch)
+	if len(got) != 1 || got[0].Group != "keep-group" {
+		t.Fatalf("got %+v, want only the keep-group metric", got)
+	}
+}
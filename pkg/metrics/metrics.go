@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KafkaOffsetMetric represent current offset of a topic partition
+type KafkaOffsetMetric struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// KafkaConsumerGroupOffsetMetric represent current offset of a consumer group on a topic partition
+type KafkaConsumerGroupOffsetMetric struct {
+	Group     string `json:"group"`
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Lag       int64  `json:"lag"`
+}
+
+// KafkaTopicRateMetric represent production rate of a topic partition
+type KafkaTopicRateMetric struct {
+	Topic     string  `json:"topic"`
+	Partition int32   `json:"partition"`
+	Rate      float64 `json:"rate"`
+}
+
+// KafkaConsumerGroupRateMetric represent consumption rate of a consumer group on a topic partition
+type KafkaConsumerGroupRateMetric struct {
+	Group     string  `json:"group"`
+	Topic     string  `json:"topic"`
+	Partition int32   `json:"partition"`
+	Rate      float64 `json:"rate"`
+}
+
+// Sink receive metrics and forward them to an external system
+type Sink interface {
+	// SendOffsetMetrics return offset channel
+	SendOffsetMetrics() chan<- []KafkaOffsetMetric
+	// SendConsumerGroupOffsetMetrics return consumer group offset channel
+	SendConsumerGroupOffsetMetrics() chan<- []KafkaConsumerGroupOffsetMetric
+	// SendTopicRateMetrics return topic rate offset channel
+	SendTopicRateMetrics() chan<- []KafkaTopicRateMetric
+	// SendConsumerGroupRateMetrics return consumer group rate offset channel
+	SendConsumerGroupRateMetrics() chan<- []KafkaConsumerGroupRateMetric
+	// Close close sink and release its resources
+	Close() error
+	// Wait blocks until the sink has flushed everything it owns
+	Wait()
+}
+
+// SinkFactory builds a new Sink instance
+type SinkFactory func() (Sink, error)
+
+var sinkFactories = map[string]SinkFactory{}
+
+// RegisterSink registers a SinkFactory under name so it can be selected with --sink
+func RegisterSink(name string, factory SinkFactory) {
+	sinkFactories[name] = factory
+}
+
+// New builds the Sink(s) registered under name. name may be a single sink
+// (e.g. "kafka") or a comma-separated list (e.g. "kafka,log"). The returned
+// Sink always fans batches out through compositeSink, even for a single
+// sink, so that sink's Filterer (e.g. kafka-sink-topics-include/exclude) is
+// applied the same way regardless of how many sinks are configured.
+func New(name string) (Sink, error) {
+	names := strings.Split(name, ",")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+
+	sinks := make([]Sink, 0, len(names))
+	for _, n := range names {
+		sink, err := newSink(n)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return newCompositeSink(sinks), nil
+}
+
+func newSink(name string) (Sink, error) {
+	factory, ok := sinkFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q", name)
+	}
+	return factory()
+}
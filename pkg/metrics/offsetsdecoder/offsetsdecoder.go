@@ -0,0 +1,155 @@
+// Package offsetsdecoder decodes the binary key/value records Kafka appends
+// to the internal __consumer_offsets topic, so callers can observe group
+// commits directly instead of polling OffsetFetch/ListGroups.
+package offsetsdecoder
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// KeyType identifies what kind of record a __consumer_offsets key encodes.
+type KeyType int
+
+const (
+	// OffsetCommitKeyType is an offset commit (key schema version 0 or 1).
+	OffsetCommitKeyType KeyType = iota
+	// GroupMetadataKeyType is a group metadata record (key schema version 2), not an offset commit.
+	GroupMetadataKeyType
+)
+
+// OffsetCommitKey identifies the (group, topic, partition) an offset commit applies to.
+type OffsetCommitKey struct {
+	Version   int16
+	Group     string
+	Topic     string
+	Partition int32
+}
+
+// OffsetCommitValue carries the committed offset and commit metadata.
+type OffsetCommitValue struct {
+	Version         int16
+	Offset          int64
+	Metadata        string
+	CommitTimestamp int64
+}
+
+// DecodeKey parses a __consumer_offsets record key. It returns
+// GroupMetadataKeyType (and a nil OffsetCommitKey) for key schema version 2,
+// which carries group metadata rather than an offset commit.
+func DecodeKey(data []byte) (KeyType, *OffsetCommitKey, error) {
+	if len(data) < 2 {
+		return 0, nil, fmt.Errorf("offsetsdecoder: key too short: %d bytes", len(data))
+	}
+	version := int16(binary.BigEndian.Uint16(data))
+	if version == 2 {
+		return GroupMetadataKeyType, nil, nil
+	}
+	if version != 0 && version != 1 {
+		return 0, nil, fmt.Errorf("offsetsdecoder: unsupported key schema version %d", version)
+	}
+
+	r := newReader(data[2:])
+	group, err := r.readString()
+	if err != nil {
+		return 0, nil, err
+	}
+	topic, err := r.readString()
+	if err != nil {
+		return 0, nil, err
+	}
+	partition, err := r.readInt32()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return OffsetCommitKeyType, &OffsetCommitKey{
+		Version:   version,
+		Group:     group,
+		Topic:     topic,
+		Partition: partition,
+	}, nil
+}
+
+// DecodeValue parses a __consumer_offsets record value for an offset commit.
+func DecodeValue(data []byte) (*OffsetCommitValue, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("offsetsdecoder: value too short: %d bytes", len(data))
+	}
+	version := int16(binary.BigEndian.Uint16(data))
+	if version != 0 && version != 1 {
+		return nil, fmt.Errorf("offsetsdecoder: unsupported value schema version %d", version)
+	}
+
+	r := newReader(data[2:])
+	offset, err := r.readInt64()
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := r.readString()
+	if err != nil {
+		return nil, err
+	}
+	commitTimestamp, err := r.readInt64()
+	if err != nil {
+		return nil, err
+	}
+	if version == 1 {
+		// version 1 carries an extra expire_timestamp after commit_timestamp, unused here.
+		if _, err := r.readInt64(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &OffsetCommitValue{
+		Version:         version,
+		Offset:          offset,
+		Metadata:        metadata,
+		CommitTimestamp: commitTimestamp,
+	}, nil
+}
+
+// reader walks a big-endian encoded buffer, matching Kafka's wire format.
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func newReader(data []byte) *reader {
+	return &reader{data: data}
+}
+
+func (r *reader) readInt32() (int32, error) {
+	if r.pos+4 > len(r.data) {
+		return 0, fmt.Errorf("offsetsdecoder: truncated int32")
+	}
+	v := int32(binary.BigEndian.Uint32(r.data[r.pos:]))
+	r.pos += 4
+	return v, nil
+}
+
+func (r *reader) readInt64() (int64, error) {
+	if r.pos+8 > len(r.data) {
+		return 0, fmt.Errorf("offsetsdecoder: truncated int64")
+	}
+	v := int64(binary.BigEndian.Uint64(r.data[r.pos:]))
+	r.pos += 8
+	return v, nil
+}
+
+func (r *reader) readString() (string, error) {
+	if r.pos+2 > len(r.data) {
+		return "", fmt.Errorf("offsetsdecoder: truncated string length")
+	}
+	length := int16(binary.BigEndian.Uint16(r.data[r.pos:]))
+	r.pos += 2
+	if length < 0 {
+		return "", nil
+	}
+	if r.pos+int(length) > len(r.data) {
+		return "", fmt.Errorf("offsetsdecoder: truncated string")
+	}
+	v := string(r.data[r.pos : r.pos+int(length)])
+	r.pos += int(length)
+	return v, nil
+}
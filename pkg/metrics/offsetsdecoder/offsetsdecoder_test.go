@@ -0,0 +1,121 @@
+package offsetsdecoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func encodeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, int16(len(s)))
+	buf.WriteString(s)
+}
+
+func buildKey(version int16, group, topic string, partition int32) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, version)
+	encodeString(buf, group)
+	encodeString(buf, topic)
+	binary.Write(buf, binary.BigEndian, partition)
+	return buf.Bytes()
+}
+
+func TestDecodeKey(t *testing.T) {
+	t.Run("group metadata", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		binary.Write(buf, binary.BigEndian, int16(2))
+		keyType, key, err := DecodeKey(buf.Bytes())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if keyType != GroupMetadataKeyType {
+			t.Fatalf("expected GroupMetadataKeyType, got %v", keyType)
+		}
+		if key != nil {
+			t.Fatalf("expected nil key, got %+v", key)
+		}
+	})
+
+	for _, version := range []int16{0, 1} {
+		version := version
+		t.Run("offset commit", func(t *testing.T) {
+			data := buildKey(version, "my-group", "my-topic", 3)
+			keyType, key, err := DecodeKey(data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if keyType != OffsetCommitKeyType {
+				t.Fatalf("expected OffsetCommitKeyType, got %v", keyType)
+			}
+			want := &OffsetCommitKey{Version: version, Group: "my-group", Topic: "my-topic", Partition: 3}
+			if *key != *want {
+				t.Fatalf("got %+v, want %+v", key, want)
+			}
+		})
+	}
+
+	t.Run("unsupported version", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		binary.Write(buf, binary.BigEndian, int16(9))
+		if _, _, err := DecodeKey(buf.Bytes()); err == nil {
+			t.Fatal("expected an error for an unsupported key schema version")
+		}
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		if _, _, err := DecodeKey([]byte{0}); err == nil {
+			t.Fatal("expected an error for a truncated key")
+		}
+	})
+}
+
+func buildValue(version int16, offset int64, metadata string, commitTimestamp, expireTimestamp int64) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, version)
+	binary.Write(buf, binary.BigEndian, offset)
+	encodeString(buf, metadata)
+	binary.Write(buf, binary.BigEndian, commitTimestamp)
+	if version == 1 {
+		binary.Write(buf, binary.BigEndian, expireTimestamp)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeValue(t *testing.T) {
+	t.Run("version 0", func(t *testing.T) {
+		data := buildValue(0, 42, "meta", 1000, 0)
+		value, err := DecodeValue(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := &OffsetCommitValue{Version: 0, Offset: 42, Metadata: "meta", CommitTimestamp: 1000}
+		if *value != *want {
+			t.Fatalf("got %+v, want %+v", value, want)
+		}
+	})
+
+	t.Run("version 1 reads commit_timestamp before expire_timestamp", func(t *testing.T) {
+		data := buildValue(1, 42, "meta", 1000, 2000)
+		value, err := DecodeValue(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if value.CommitTimestamp != 1000 {
+			t.Fatalf("expected CommitTimestamp 1000, got %d", value.CommitTimestamp)
+		}
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		binary.Write(buf, binary.BigEndian, int16(9))
+		if _, err := DecodeValue(buf.Bytes()); err == nil {
+			t.Fatal("expected an error for an unsupported value schema version")
+		}
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		if _, err := DecodeValue([]byte{0}); err == nil {
+			t.Fatal("expected an error for a truncated value")
+		}
+	})
+}
@@ -0,0 +1,298 @@
+package metrics
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Sirupsen/logrus"
+	"github.com/ryarnyah/kafka-offset/pkg/metrics/offsetsdecoder"
+	"github.com/ryarnyah/kafka-offset/pkg/util"
+)
+
+const consumerOffsetsTopic = "__consumer_offsets"
+
+var (
+	kafkaSourceConsumerOffsetsGroup = flag.String("kafka-source-consumer-offsets-group", "kafka-offset-exporter", "Consumer group id used to join __consumer_offsets in --source-mode=consumer-offsets")
+	kafkaSourceFallbackInterval     = flag.Duration("kafka-source-fallback-interval", 60*time.Second, "Interval at which --source-mode=consumer-offsets still queries end-of-log offsets to compute lag")
+	kafkaSourceEndOffsetTTL         = flag.Duration("kafka-source-end-offset-ttl", 5*time.Second, "How long a topic partition's end-of-log offset is cached before handle() issues a fresh GetOffset call")
+)
+
+// offsetCacheKey identifies a single consumer group's position on one topic partition.
+type offsetCacheKey struct {
+	group     string
+	topic     string
+	partition int32
+}
+
+// offsetCacheEntry is the last offset commit observed for a key, used to derive commit rate.
+type offsetCacheEntry struct {
+	offset   int64
+	observed time.Time
+}
+
+// endOffsetCacheKey identifies a single topic partition's end-of-log offset.
+type endOffsetCacheKey struct {
+	topic     string
+	partition int32
+}
+
+// endOffsetCacheEntry is the last end-of-log offset fetched for a key, kept
+// for kafka-source-end-offset-ttl so a busy partition with many committing
+// groups doesn't trigger a GetOffset broker round-trip per commit.
+type endOffsetCacheEntry struct {
+	offset   int64
+	observed time.Time
+}
+
+// consumerOffsetsSource watches __consumer_offsets directly instead of
+// issuing OffsetFetch/ListGroups admin calls, so commits are seen in
+// real time and broker load stays flat regardless of group count.
+type consumerOffsetsSource struct {
+	sink   Sink
+	client sarama.Client
+	group  sarama.ConsumerGroup
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	cache map[offsetCacheKey]offsetCacheEntry
+
+	endMu    sync.Mutex
+	endCache map[endOffsetCacheKey]endOffsetCacheEntry
+
+	stopCh chan interface{}
+	wg     sync.WaitGroup
+}
+
+// newConsumerOffsetsSource builds a source that joins __consumer_offsets as
+// its own consumer group and decodes commits as they are produced.
+func newConsumerOffsetsSource(sink Sink) (Source, error) {
+	cfg := sarama.NewConfig()
+	cfg.ClientID = "kafka-offset"
+	cfg.Version = sarama.V0_11_0_0
+	cfg.Consumer.Offsets.Initial = sarama.OffsetNewest
+
+	var err error
+	cfg.Net.TLS.Config, cfg.Net.TLS.Enable, err = util.GetTLSConfiguration(*kafkaSourceCacerts, *kafkaSourceCert, *kafkaSourceKey, *kafkaSourceInsecure)
+	if err != nil {
+		return nil, err
+	}
+	if err := util.GetSASLConfigurationV2(cfg, *kafkaSourceUsername, *kafkaSourcePassword, *kafkaSourceSASLMechanism, *kafkaSourceSASLTokenCmd); err != nil {
+		return nil, err
+	}
+
+	brokerList := strings.Split(*kafkaSourceBrokers, ",")
+	client, err := sarama.NewClient(brokerList, cfg)
+	if err != nil {
+		return nil, err
+	}
+	group, err := sarama.NewConsumerGroupFromClient(*kafkaSourceConsumerOffsetsGroup, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &consumerOffsetsSource{
+		sink:     sink,
+		client:   client,
+		group:    group,
+		cache:    make(map[offsetCacheKey]offsetCacheEntry),
+		endCache: make(map[endOffsetCacheKey]endOffsetCacheEntry),
+		stopCh:   make(chan interface{}),
+	}, nil
+}
+
+// Run joins __consumer_offsets and starts the end-of-log fallback ticker.
+func (s *consumerOffsetsSource) Run() chan interface{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			if err := s.group.Consume(ctx, []string{consumerOffsetsTopic}, s); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logrus.Error(err)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(*kafkaSourceFallbackInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.emitLag()
+			case <-s.stopCh:
+				logrus.Info("Kafka consumer-offsets source stopped")
+				return
+			}
+		}
+	}()
+
+	return s.stopCh
+}
+
+// Setup implements sarama.ConsumerGroupHandler
+func (s *consumerOffsetsSource) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// Cleanup implements sarama.ConsumerGroupHandler
+func (s *consumerOffsetsSource) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim decodes every __consumer_offsets record in claim, updates the
+// commit cache and emits a rate metric, marking each message only once
+// decoded and dispatched.
+func (s *consumerOffsetsSource) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		s.handle(msg)
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+func (s *consumerOffsetsSource) handle(msg *sarama.ConsumerMessage) {
+	keyType, key, err := offsetsdecoder.DecodeKey(msg.Key)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	if keyType != offsetsdecoder.OffsetCommitKeyType {
+		// Group metadata record (key schema version 2): not an offset commit.
+		return
+	}
+	if len(msg.Value) == 0 {
+		// Tombstone: the group or its commit for this partition was deleted.
+		s.mu.Lock()
+		delete(s.cache, offsetCacheKey{group: key.Group, topic: key.Topic, partition: key.Partition})
+		s.mu.Unlock()
+		return
+	}
+	value, err := offsetsdecoder.DecodeValue(msg.Value)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	cacheKey := offsetCacheKey{group: key.Group, topic: key.Topic, partition: key.Partition}
+	now := time.Now()
+
+	s.mu.Lock()
+	prev, ok := s.cache[cacheKey]
+	s.cache[cacheKey] = offsetCacheEntry{offset: value.Offset, observed: now}
+	s.mu.Unlock()
+
+	if ok {
+		if elapsed := now.Sub(prev.observed).Seconds(); elapsed > 0 {
+			s.sink.SendConsumerGroupRateMetrics() <- []KafkaConsumerGroupRateMetric{{
+				Group:     key.Group,
+				Topic:     key.Topic,
+				Partition: key.Partition,
+				Rate:      float64(value.Offset-prev.offset) / elapsed,
+			}}
+		}
+	}
+
+	// Emit the offset/lag metric off this commit directly, so lag freshness
+	// matches the rate metric instead of waiting for the fallback ticker.
+	// endOffset is debounced per topic partition so a busy partition with
+	// many committing groups doesn't issue a GetOffset round-trip per commit.
+	end, err := s.endOffset(key.Topic, key.Partition)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	s.sink.SendConsumerGroupOffsetMetrics() <- []KafkaConsumerGroupOffsetMetric{{
+		Group:     key.Group,
+		Topic:     key.Topic,
+		Partition: key.Partition,
+		Offset:    value.Offset,
+		Lag:       end - value.Offset,
+	}}
+}
+
+// endOffset returns the end-of-log offset for (topic, partition), reusing a
+// cached value younger than kafka-source-end-offset-ttl instead of issuing a
+// fresh GetOffset broker call for every commit.
+func (s *consumerOffsetsSource) endOffset(topic string, partition int32) (int64, error) {
+	key := endOffsetCacheKey{topic: topic, partition: partition}
+
+	s.endMu.Lock()
+	entry, ok := s.endCache[key]
+	s.endMu.Unlock()
+	if ok && time.Since(entry.observed) < *kafkaSourceEndOffsetTTL {
+		return entry.offset, nil
+	}
+
+	offset, err := s.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return 0, err
+	}
+
+	s.endMu.Lock()
+	s.endCache[key] = endOffsetCacheEntry{offset: offset, observed: time.Now()}
+	s.endMu.Unlock()
+
+	return offset, nil
+}
+
+// emitLag is the fallback path: it queries end-of-log offsets for every
+// (group, topic, partition) seen so far and derives lag from the cached
+// last committed offset, so lag stays available even between commits.
+func (s *consumerOffsetsSource) emitLag() {
+	s.mu.Lock()
+	snapshot := make(map[offsetCacheKey]int64, len(s.cache))
+	for key, entry := range s.cache {
+		snapshot[key] = entry.offset
+	}
+	s.mu.Unlock()
+
+	byGroup := make(map[string][]KafkaConsumerGroupOffsetMetric)
+	for key, offset := range snapshot {
+		end, err := s.endOffset(key.topic, key.partition)
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+		byGroup[key.group] = append(byGroup[key.group], KafkaConsumerGroupOffsetMetric{
+			Group:     key.group,
+			Topic:     key.topic,
+			Partition: key.partition,
+			Offset:    offset,
+			Lag:       end - offset,
+		})
+	}
+	for _, groupMetrics := range byGroup {
+		s.sink.SendConsumerGroupOffsetMetrics() <- groupMetrics
+	}
+}
+
+// Close stops consuming __consumer_offsets and the fallback ticker.
+func (s *consumerOffsetsSource) Close() error {
+	close(s.stopCh)
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	err := s.group.Close()
+	s.client.Close()
+	return err
+}
+
+// Wait returns at once; Close waits for the consumer and fallback loops to stop
+func (s *consumerOffsetsSource) Wait() {
+}
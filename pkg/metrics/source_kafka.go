@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Sirupsen/logrus"
+	"github.com/ryarnyah/kafka-offset/pkg/util"
+)
+
+// Source reads kafka offsets/lag and pushes them to a Sink
+type Source interface {
+	// Run starts polling and returns a channel that is closed once the source is asked to stop
+	Run() chan interface{}
+	// Close stops the source and releases its resources
+	Close() error
+	// Wait is called once the source has been asked to stop; the sources in
+	// this package return from it at once and join their goroutines in Close
+	Wait()
+}
+
+var (
+	kafkaSourceBrokers       = flag.String("kafka-source-brokers", "localhost:9092", "Kafka source brokers")
+	kafkaSourceCacerts       = flag.String("kafka-source-ssl-cacerts", "", "Kafka SSL cacerts")
+	kafkaSourceCert          = flag.String("kafka-source-ssl-cert", "", "Kafka SSL cert")
+	kafkaSourceKey           = flag.String("kafka-source-ssl-key", "", "Kafka SSL key")
+	kafkaSourceInsecure      = flag.Bool("kafka-source-ssl-insecure", false, "Kafka insecure ssl connection")
+	kafkaSourceUsername      = flag.String("kafka-source-sasl-username", "", "Kafka SASL username")
+	kafkaSourcePassword      = flag.String("kafka-source-sasl-password", "", "Kafka SASL password")
+	kafkaSourceSASLMechanism = flag.String("kafka-source-sasl-mechanism", util.SASLMechanismPlain, "Kafka source SASL mechanism: PLAIN|SCRAM-SHA-256|SCRAM-SHA-512|OAUTHBEARER")
+	kafkaSourceSASLTokenCmd  = flag.String("kafka-source-sasl-token-cmd", "", "Command whose stdout is a JWT, used with --kafka-source-sasl-mechanism=OAUTHBEARER")
+	kafkaSourceInterval      = flag.Duration("kafka-source-interval", 30*time.Second, "Interval between two offset polls")
+
+	sourceMode = flag.String("source-mode", "poll", "How to observe consumer group offsets: poll|consumer-offsets")
+)
+
+// kafkaSource polls __consumer_offsets metadata through admin calls
+type kafkaSource struct {
+	sink   Sink
+	client sarama.Client
+	admin  sarama.ClusterAdmin
+
+	stopCh chan interface{}
+	wg     sync.WaitGroup
+}
+
+// NewKafkaSource builds the kafka Source selected by --source-mode: "poll"
+// (the default) issues OffsetFetch/ListGroups admin calls on an interval,
+// "consumer-offsets" instead watches the __consumer_offsets topic directly.
+func NewKafkaSource(sink Sink) (Source, error) {
+	switch *sourceMode {
+	case "consumer-offsets":
+		return newConsumerOffsetsSource(sink)
+	case "poll":
+		return newPollSource(sink)
+	default:
+		return nil, fmt.Errorf("unknown source-mode %q", *sourceMode)
+	}
+}
+
+// newPollSource builds a kafka source polling consumer group lag on an interval
+func newPollSource(sink Sink) (Source, error) {
+	cfg := sarama.NewConfig()
+	cfg.ClientID = "kafka-offset"
+	cfg.Version = sarama.V0_10_0_0
+
+	var err error
+	cfg.Net.TLS.Config, cfg.Net.TLS.Enable, err = util.GetTLSConfiguration(*kafkaSourceCacerts, *kafkaSourceCert, *kafkaSourceKey, *kafkaSourceInsecure)
+	if err != nil {
+		return nil, err
+	}
+	if err := util.GetSASLConfigurationV2(cfg, *kafkaSourceUsername, *kafkaSourcePassword, *kafkaSourceSASLMechanism, *kafkaSourceSASLTokenCmd); err != nil {
+		return nil, err
+	}
+
+	brokerList := strings.Split(*kafkaSourceBrokers, ",")
+	client, err := sarama.NewClient(brokerList, cfg)
+	if err != nil {
+		return nil, err
+	}
+	admin, err := sarama.NewClusterAdminFromClient(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaSource{
+		sink:   sink,
+		client: client,
+		admin:  admin,
+		stopCh: make(chan interface{}),
+	}, nil
+}
+
+// Run polls groups/topics offsets every kafka-source-interval until Close is called
+func (s *kafkaSource) Run() chan interface{} {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(*kafkaSourceInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.poll()
+			case <-s.stopCh:
+				logrus.Info("Kafka source stopped")
+				return
+			}
+		}
+	}()
+	return s.stopCh
+}
+
+func (s *kafkaSource) poll() {
+	groups, err := s.admin.ListConsumerGroups()
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	groupNames := make([]string, 0, len(groups))
+	for name := range groups {
+		groupNames = append(groupNames, name)
+	}
+
+	for _, group := range groupNames {
+		offsets, err := s.admin.ListConsumerGroupOffsets(group, nil)
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+		var groupMetrics []KafkaConsumerGroupOffsetMetric
+		for topic, partitions := range offsets.Blocks {
+			for partition, block := range partitions {
+				if block.Offset < 0 {
+					continue
+				}
+				end, err := s.client.GetOffset(topic, partition, sarama.OffsetNewest)
+				if err != nil {
+					logrus.Error(err)
+					continue
+				}
+				groupMetrics = append(groupMetrics, KafkaConsumerGroupOffsetMetric{
+					Group:     group,
+					Topic:     topic,
+					Partition: partition,
+					Offset:    block.Offset,
+					Lag:       end - block.Offset,
+				})
+			}
+		}
+		if len(groupMetrics) > 0 {
+			s.sink.SendConsumerGroupOffsetMetrics() <- groupMetrics
+		}
+	}
+}
+
+// Close stops the polling loop
+func (s *kafkaSource) Close() error {
+	close(s.stopCh)
+	s.wg.Wait()
+	s.client.Close()
+	return nil
+}
+
+// Wait returns at once; Close waits for the polling loop to stop
+func (s *kafkaSource) Wait() {
+}
@@ -3,9 +3,13 @@ package sinks
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/Sirupsen/logrus"
@@ -13,6 +17,13 @@ import (
 	"github.com/ryarnyah/kafka-offset/pkg/util"
 )
 
+// sinkRetryMetadata is carried on a ProducerMessage's Metadata so the
+// dispatch loop can re-queue it (up to kafkaSinkMaxRetries times) after a
+// delivery error without re-marshalling the original metric.
+type sinkRetryMetadata struct {
+	retries int
+}
+
 func init() {
 	metrics.RegisterSink("kafka", NewKafkaSink)
 }
@@ -25,21 +36,56 @@ type KafkaSink struct {
 	groupRateChan chan []metrics.KafkaConsumerGroupRateMetric
 	stopCh        chan interface{}
 
-	producer sarama.SyncProducer
+	client   sarama.Client
+	admin    sarama.ClusterAdmin
+	producer sarama.AsyncProducer
 	topic    string
 
-	wg sync.WaitGroup
+	topicsInclude *regexp.Regexp
+	topicsExclude *regexp.Regexp
+	groupsInclude *regexp.Regexp
+	groupsExclude *regexp.Regexp
+
+	retryBuffer chan *sarama.ProducerMessage
+	inFlight    int64
+	retryCount  int64
+
+	wg         sync.WaitGroup
+	dispatchWg sync.WaitGroup
 }
 
 var (
-	kafkaSinkBrokers  = flag.String("kafka-sink-brokers", "localhost:9092", "Kafka sink brokers")
-	kafkaSinkCacerts  = flag.String("kafka-sink-ssl-cacerts", "", "Kafka SSL cacerts")
-	kafkaSinkCert     = flag.String("kafka-sink-ssl-cert", "", "Kafka SSL cert")
-	kafkaSinkKey      = flag.String("kafka-sink-ssl-key", "", "Kafka SSL key")
-	kafkaSinkInsecure = flag.Bool("kafka-sink-ssl-insecure", false, "Kafka insecure ssl connection")
-	kafkaSinkUsername = flag.String("kafka-sink-sasl-username", os.Getenv("SINK_KAFKA_USERNAME"), "Kafka SASL username")
-	kafkaSinkPassword = flag.String("kafka-sink-sasl-password", os.Getenv("SINK_KAFKA_PASSWORD"), "Kafka SASL password")
-	kafkaSinkTopic    = flag.String("kafka-sink-topic", "metrics", "Kafka topic to send metrics")
+	kafkaSinkBrokers       = flag.String("kafka-sink-brokers", "localhost:9092", "Kafka sink brokers")
+	kafkaSinkCacerts       = flag.String("kafka-sink-ssl-cacerts", "", "Kafka SSL cacerts")
+	kafkaSinkCert          = flag.String("kafka-sink-ssl-cert", "", "Kafka SSL cert")
+	kafkaSinkKey           = flag.String("kafka-sink-ssl-key", "", "Kafka SSL key")
+	kafkaSinkInsecure      = flag.Bool("kafka-sink-ssl-insecure", false, "Kafka insecure ssl connection")
+	kafkaSinkUsername      = flag.String("kafka-sink-sasl-username", os.Getenv("SINK_KAFKA_USERNAME"), "Kafka SASL username")
+	kafkaSinkPassword      = flag.String("kafka-sink-sasl-password", os.Getenv("SINK_KAFKA_PASSWORD"), "Kafka SASL password")
+	kafkaSinkSASLMechanism = flag.String("kafka-sink-sasl-mechanism", util.SASLMechanismPlain, "Kafka sink SASL mechanism: PLAIN|SCRAM-SHA-256|SCRAM-SHA-512|OAUTHBEARER")
+	kafkaSinkSASLTokenCmd  = flag.String("kafka-sink-sasl-token-cmd", "", "Command whose stdout is a JWT, used with --kafka-sink-sasl-mechanism=OAUTHBEARER")
+	kafkaSinkTopic         = flag.String("kafka-sink-topic", "metrics", "Kafka topic to send metrics")
+
+	kafkaSinkTopicsInclude = flag.String("kafka-sink-topics-include", "", "Only forward metrics for topics matching this regex to the kafka sink")
+	kafkaSinkTopicsExclude = flag.String("kafka-sink-topics-exclude", "", "Drop metrics for topics matching this regex from the kafka sink")
+	kafkaSinkGroupsInclude = flag.String("kafka-sink-groups-include", "", "Only forward metrics for consumer groups matching this regex to the kafka sink")
+	kafkaSinkGroupsExclude = flag.String("kafka-sink-groups-exclude", "", "Drop metrics for consumer groups matching this regex from the kafka sink")
+
+	kafkaSinkVersion          = flag.String("kafka-sink-version", "0.11.0.0", "Kafka protocol version spoken to the sink brokers, e.g. 0.11.0.0, 2.1.0")
+	kafkaSinkCompression      = flag.String("kafka-sink-compression", "none", "Kafka sink producer compression codec: none|gzip|snappy|lz4|zstd")
+	kafkaSinkFlushFrequency   = flag.Duration("kafka-sink-flush-frequency", 500*time.Millisecond, "Kafka sink producer batch flush frequency")
+	kafkaSinkFlushMaxMessages = flag.Int("kafka-sink-flush-max-messages", 1000, "Kafka sink producer max messages per batch")
+	kafkaSinkRetryBuffer      = flag.Int("kafka-sink-retry-buffer", 1024, "Size of the bounded in-memory retry buffer for failed sends")
+	kafkaSinkMaxRetries       = flag.Int("kafka-sink-max-retries", 3, "Max re-queue attempts for a message before it is dropped")
+
+	kafkaSinkAutoCreate        = flag.Bool("kafka-sink-auto-create", false, "Create kafka-sink-topic on startup if it doesn't already exist")
+	kafkaSinkPartitions        = flag.Int("kafka-sink-partitions", 1, "Partitions to use when auto-creating the sink topic")
+	kafkaSinkReplicationFactor = flag.Int("kafka-sink-replication-factor", 1, "Replication factor to use when auto-creating the sink topic")
+	kafkaSinkRetentionMs       = flag.String("kafka-sink-retention-ms", "604800000", "retention.ms to set when auto-creating the sink topic")
+	kafkaSinkCleanupPolicy     = flag.String("kafka-sink-cleanup-policy", "delete", "cleanup.policy to set when auto-creating the sink topic: compact|delete")
+	kafkaSinkMetaRefresh       = flag.Duration("kafka-sink-meta-refresh", 10*time.Minute, "Interval to re-verify the sink topic, picking up partition additions without a restart")
+
+	kafkaSinkStatsInterval = flag.Duration("kafka-sink-stats-interval", 30*time.Second, "Interval at which in-flight batch size and retry count are logged")
 )
 
 // SendOffsetMetrics return offset channel
@@ -62,6 +108,16 @@ func (sink *KafkaSink) SendConsumerGroupRateMetrics() chan<- []metrics.KafkaCons
 	return sink.groupRateChan
 }
 
+// IncludeTopic reports whether metrics for topic should be forwarded to this sink
+func (sink *KafkaSink) IncludeTopic(topic string) bool {
+	return metrics.MatchFilter(sink.topicsInclude, sink.topicsExclude, topic)
+}
+
+// IncludeGroup reports whether metrics for group should be forwarded to this sink
+func (sink *KafkaSink) IncludeGroup(group string) bool {
+	return metrics.MatchFilter(sink.groupsInclude, sink.groupsExclude, group)
+}
+
 // Close close producer and channels
 func (sink *KafkaSink) Close() error {
 	close(sink.stopCh)
@@ -70,11 +126,23 @@ func (sink *KafkaSink) Close() error {
 	close(sink.groupChan)
 	close(sink.topicRateChan)
 	close(sink.groupRateChan)
-	err := sink.producer.Close()
-	if err != nil {
-		return err
+	// AsyncClose instead of Close: it only triggers the drain, leaving
+	// dispatch() as the sole reader of Successes()/Errors(). Close() drains
+	// those channels itself, which would race dispatch() for every result.
+	sink.producer.AsyncClose()
+	sink.dispatchWg.Wait()
+	return sink.client.Close()
+}
+
+// closing reports whether Close has started, so in-flight retries stop
+// feeding the producer's Input channel instead of racing its Close call.
+func (sink *KafkaSink) closing() bool {
+	select {
+	case <-sink.stopCh:
+		return true
+	default:
+		return false
 	}
-	return nil
 }
 
 // Wait sync.Waitgroup until close
@@ -82,7 +150,124 @@ func (sink *KafkaSink) Wait() {
 
 }
 
+// send marshals metric and hands it to the async producer, counting it as in-flight.
+func (sink *KafkaSink) send(metric interface{}) {
+	b, err := json.Marshal(metric)
+	if err != nil {
+		logrus.Error(err)
+		return
+	}
+	atomic.AddInt64(&sink.inFlight, 1)
+	sink.producer.Input() <- &sarama.ProducerMessage{
+		Topic:    sink.topic,
+		Value:    sarama.ByteEncoder(b),
+		Metadata: &sinkRetryMetadata{},
+	}
+}
+
+// retry re-queues a failed message onto the retry buffer, up to kafka-sink-max-retries,
+// dropping it (with a logged error) once exhausted or once the buffer is full.
+func (sink *KafkaSink) retry(perr *sarama.ProducerError) {
+	atomic.AddInt64(&sink.retryCount, 1)
+	if sink.closing() {
+		logrus.Errorf("kafka sink: dropping message, sink is closing: %v", perr.Err)
+		return
+	}
+
+	meta, _ := perr.Msg.Metadata.(*sinkRetryMetadata)
+	if meta == nil {
+		meta = &sinkRetryMetadata{}
+	}
+	if meta.retries >= *kafkaSinkMaxRetries {
+		logrus.Errorf("kafka sink: dropping message after %d retries: %v", meta.retries, perr.Err)
+		return
+	}
+	meta.retries++
+	perr.Msg.Metadata = meta
+
+	select {
+	case sink.retryBuffer <- perr.Msg:
+	default:
+		logrus.Errorf("kafka sink: retry buffer full (%d), dropping message: %v", *kafkaSinkRetryBuffer, perr.Err)
+	}
+}
+
+// resend hands a retry-buffered message back to the producer's input channel.
+func (sink *KafkaSink) resend(msg *sarama.ProducerMessage) {
+	if sink.closing() {
+		logrus.Error("kafka sink: dropping buffered message, sink is closing")
+		return
+	}
+	atomic.AddInt64(&sink.inFlight, 1)
+	select {
+	case sink.producer.Input() <- msg:
+	default:
+		atomic.AddInt64(&sink.inFlight, -1)
+		select {
+		case sink.retryBuffer <- msg:
+		default:
+			logrus.Error("kafka sink: dropping message, producer and retry buffer are both full")
+		}
+	}
+}
+
+// dispatch is the single loop draining the producer's Successes/Errors and
+// the retry buffer, so sends never block on one another's bookkeeping.
+func (sink *KafkaSink) dispatch() {
+	defer sink.dispatchWg.Done()
+	successes := sink.producer.Successes()
+	errors := sink.producer.Errors()
+	for successes != nil || errors != nil {
+		select {
+		case _, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			atomic.AddInt64(&sink.inFlight, -1)
+		case perr, ok := <-errors:
+			if !ok {
+				errors = nil
+				continue
+			}
+			atomic.AddInt64(&sink.inFlight, -1)
+			sink.retry(perr)
+		case msg := <-sink.retryBuffer:
+			sink.resend(msg)
+		}
+	}
+	logrus.Infof("Kafka sink dispatch stopped, %d messages retried", atomic.LoadInt64(&sink.retryCount))
+}
+
+// statsLoop periodically logs in-flight batch size and retry count, so an
+// operator can observe producer backpressure and retry behavior without
+// attaching a profiler.
+func (sink *KafkaSink) statsLoop() {
+	defer sink.wg.Done()
+	ticker := time.NewTicker(*kafkaSinkStatsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			logrus.Infof("kafka sink stats: in-flight=%d retries=%d", atomic.LoadInt64(&sink.inFlight), atomic.LoadInt64(&sink.retryCount))
+		case <-sink.stopCh:
+			return
+		}
+	}
+}
+
 func (sink *KafkaSink) run() {
+	sink.dispatchWg.Add(1)
+	go sink.dispatch()
+
+	sink.wg.Add(1)
+	go sink.statsLoop()
+
+	if sink.admin != nil {
+		sink.wg.Add(1)
+		go sink.refreshTopicLoop()
+	}
+
 	sink.wg.Add(1)
 	go func(s *KafkaSink) {
 		defer s.wg.Done()
@@ -90,18 +275,7 @@ func (sink *KafkaSink) run() {
 			select {
 			case metrics := <-s.groupChan:
 				for _, metric := range metrics {
-					b, err := json.Marshal(metric)
-					if err != nil {
-						logrus.Error(err)
-					} else {
-						_, _, err := s.producer.SendMessage(&sarama.ProducerMessage{
-							Topic: s.topic,
-							Value: sarama.ByteEncoder(b),
-						})
-						if err != nil {
-							logrus.Error(err)
-						}
-					}
+					s.send(metric)
 				}
 			case <-s.stopCh:
 				logrus.Info("Kafka ConsumerGroupOffsetMetrics Stoped")
@@ -116,18 +290,7 @@ func (sink *KafkaSink) run() {
 			select {
 			case metrics := <-s.offsetChan:
 				for _, metric := range metrics {
-					b, err := json.Marshal(metric)
-					if err != nil {
-						logrus.Error(err)
-					} else {
-						_, _, err := s.producer.SendMessage(&sarama.ProducerMessage{
-							Topic: s.topic,
-							Value: sarama.ByteEncoder(b),
-						})
-						if err != nil {
-							logrus.Error(err)
-						}
-					}
+					s.send(metric)
 				}
 			case <-s.stopCh:
 				logrus.Info("Kafka OffsetMetrics Stoped")
@@ -142,18 +305,7 @@ func (sink *KafkaSink) run() {
 			select {
 			case metrics := <-s.groupRateChan:
 				for _, metric := range metrics {
-					b, err := json.Marshal(metric)
-					if err != nil {
-						logrus.Error(err)
-					} else {
-						_, _, err := s.producer.SendMessage(&sarama.ProducerMessage{
-							Topic: s.topic,
-							Value: sarama.ByteEncoder(b),
-						})
-						if err != nil {
-							logrus.Error(err)
-						}
-					}
+					s.send(metric)
 				}
 			case <-s.stopCh:
 				logrus.Info("Kafka GroupRateChan Stoped")
@@ -168,18 +320,7 @@ func (sink *KafkaSink) run() {
 			select {
 			case metrics := <-s.topicRateChan:
 				for _, metric := range metrics {
-					b, err := json.Marshal(metric)
-					if err != nil {
-						logrus.Error(err)
-					} else {
-						_, _, err := s.producer.SendMessage(&sarama.ProducerMessage{
-							Topic: s.topic,
-							Value: sarama.ByteEncoder(b),
-						})
-						if err != nil {
-							logrus.Error(err)
-						}
-					}
+					s.send(metric)
 				}
 			case <-s.stopCh:
 				logrus.Info("Kafka TopicRateChan Stoped")
@@ -190,22 +331,95 @@ func (sink *KafkaSink) run() {
 
 }
 
+func compileFilter(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+var kafkaSinkCompressionCodecs = map[string]sarama.CompressionCodec{
+	"none":   sarama.CompressionNone,
+	"gzip":   sarama.CompressionGZIP,
+	"snappy": sarama.CompressionSnappy,
+	"lz4":    sarama.CompressionLZ4,
+	"zstd":   sarama.CompressionZSTD,
+}
+
+// minKafkaSinkVersion is the lowest broker protocol version that supports
+// the idempotent producer this sink requires.
+var minKafkaSinkVersion = sarama.V0_11_0_0
+
 // NewKafkaSink build new kafka sink
 func NewKafkaSink() (metrics.Sink, error) {
 	var err error
 	sarama.Logger = logrus.StandardLogger()
+
+	topicsInclude, err := compileFilter(*kafkaSinkTopicsInclude)
+	if err != nil {
+		return nil, err
+	}
+	topicsExclude, err := compileFilter(*kafkaSinkTopicsExclude)
+	if err != nil {
+		return nil, err
+	}
+	groupsInclude, err := compileFilter(*kafkaSinkGroupsInclude)
+	if err != nil {
+		return nil, err
+	}
+	groupsExclude, err := compileFilter(*kafkaSinkGroupsExclude)
+	if err != nil {
+		return nil, err
+	}
+	version, err := sarama.ParseKafkaVersion(*kafkaSinkVersion)
+	if err != nil {
+		return nil, err
+	}
+	if !version.IsAtLeast(minKafkaSinkVersion) {
+		return nil, fmt.Errorf("kafka-sink-version %s is too old, idempotent delivery requires at least %s", *kafkaSinkVersion, minKafkaSinkVersion)
+	}
+	codec, ok := kafkaSinkCompressionCodecs[*kafkaSinkCompression]
+	if !ok {
+		return nil, fmt.Errorf("unknown kafka-sink-compression %q", *kafkaSinkCompression)
+	}
+
 	cfg := sarama.NewConfig()
 	cfg.ClientID = "kafka-sink"
-	cfg.Version = sarama.V0_10_0_0
+	cfg.Version = version
 	cfg.Producer.Return.Successes = true
+	cfg.Producer.Return.Errors = true
+	cfg.Producer.Compression = codec
+	cfg.Producer.Flush.Frequency = *kafkaSinkFlushFrequency
+	cfg.Producer.Flush.MaxMessages = *kafkaSinkFlushMaxMessages
+	cfg.Producer.Idempotent = true
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	cfg.Net.MaxOpenRequests = 1
 	cfg.Net.TLS.Config, cfg.Net.TLS.Enable, err = util.GetTLSConfiguration(*kafkaSinkCacerts, *kafkaSinkCert, *kafkaSinkKey, *kafkaSinkInsecure)
 	if err != nil {
 		return nil, err
 	}
-	cfg.Net.SASL.User, cfg.Net.SASL.Password, cfg.Net.SASL.Enable = util.GetSASLConfiguration(*kafkaSinkUsername, *kafkaSinkPassword)
+	if err := util.GetSASLConfigurationV2(cfg, *kafkaSinkUsername, *kafkaSinkPassword, *kafkaSinkSASLMechanism, *kafkaSinkSASLTokenCmd); err != nil {
+		return nil, err
+	}
 	brokerList := strings.Split(*kafkaSinkBrokers, ",")
 
-	producer, err := sarama.NewSyncProducer(brokerList, cfg)
+	client, err := sarama.NewClient(brokerList, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var admin sarama.ClusterAdmin
+	if *kafkaSinkAutoCreate {
+		admin, err = sarama.NewClusterAdminFromClient(client)
+		if err != nil {
+			return nil, err
+		}
+		if err := ensureSinkTopic(admin, *kafkaSinkTopic); err != nil {
+			return nil, err
+		}
+	}
+
+	producer, err := sarama.NewAsyncProducerFromClient(client)
 	if err != nil {
 		return nil, err
 	}
@@ -223,8 +437,16 @@ func NewKafkaSink() (metrics.Sink, error) {
 		groupRateChan: groupRateChan,
 		stopCh:        stopCh,
 
-		producer: producer,
-		topic:    *kafkaSinkTopic,
+		client:      client,
+		admin:       admin,
+		producer:    producer,
+		topic:       *kafkaSinkTopic,
+		retryBuffer: make(chan *sarama.ProducerMessage, *kafkaSinkRetryBuffer),
+
+		topicsInclude: topicsInclude,
+		topicsExclude: topicsExclude,
+		groupsInclude: groupsInclude,
+		groupsExclude: groupsExclude,
 	}
 	sink.run()
 	return sink, nil
@@ -0,0 +1,100 @@
+package sinks
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Sirupsen/logrus"
+)
+
+// ensureSinkTopic checks that topic exists and, when missing, creates it
+// with the configured partitions/replication/retention/cleanup policy. If
+// the sink account lacks CreateTopics ACLs it logs a clean warning and
+// falls back to send-only mode instead of failing startup.
+func ensureSinkTopic(admin sarama.ClusterAdmin, topic string) error {
+	verified, err := sinkTopicExists(admin, topic)
+	if err != nil {
+		return err
+	}
+	if verified {
+		return nil
+	}
+
+	retentionMs := *kafkaSinkRetentionMs
+	cleanupPolicy := *kafkaSinkCleanupPolicy
+	err = admin.CreateTopic(topic, &sarama.TopicDetail{
+		NumPartitions:     int32(*kafkaSinkPartitions),
+		ReplicationFactor: int16(*kafkaSinkReplicationFactor),
+		ConfigEntries: map[string]*string{
+			"retention.ms":   &retentionMs,
+			"cleanup.policy": &cleanupPolicy,
+		},
+	}, false)
+	if err != nil {
+		if isACLError(err) {
+			logrus.Warnf("kafka sink: missing CreateTopics ACL for %q, falling back to send-only mode: %v", topic, err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// sinkTopicExists reports whether topic is already present on the cluster.
+// An authorization failure while describing it is also reported as present,
+// so the caller skips creation and falls back to send-only mode.
+func sinkTopicExists(admin sarama.ClusterAdmin, topic string) (bool, error) {
+	descriptions, err := admin.DescribeTopics([]string{topic})
+	if err != nil {
+		if isACLError(err) {
+			logrus.Warnf("kafka sink: cannot describe topic %q, falling back to send-only mode: %v", topic, err)
+			return true, nil
+		}
+		return false, err
+	}
+	for _, description := range descriptions {
+		if description.Name != topic {
+			continue
+		}
+		if description.Err == sarama.ErrNoError {
+			return true, nil
+		}
+		if isACLError(description.Err) {
+			logrus.Warnf("kafka sink: not authorized to describe topic %q, falling back to send-only mode: %v", topic, description.Err)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isACLError reports whether err is a broker authorization failure, as
+// opposed to a connectivity or configuration error worth failing startup for.
+func isACLError(err error) bool {
+	kerr, ok := err.(sarama.KError)
+	if !ok {
+		return false
+	}
+	return kerr == sarama.ErrTopicAuthorizationFailed || kerr == sarama.ErrClusterAuthorizationFailed
+}
+
+// refreshTopicLoop re-verifies the sink topic on kafka-sink-meta-refresh,
+// so partitions added out of band are picked up without a restart.
+func (sink *KafkaSink) refreshTopicLoop() {
+	defer sink.wg.Done()
+	ticker := time.NewTicker(*kafkaSinkMetaRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := ensureSinkTopic(sink.admin, sink.topic); err != nil {
+				logrus.Error(err)
+				continue
+			}
+			if err := sink.client.RefreshMetadata(sink.topic); err != nil {
+				logrus.Error(err)
+			}
+		case <-sink.stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// tokenExpiryMargin forces a refresh this long before a token's exp claim,
+// so a connection attempt never races an about-to-expire token.
+const tokenExpiryMargin = 30 * time.Second
+
+// tokenCmdTimeout bounds how long sasl-token-cmd may run, so a hung command
+// (or a stalled IdP) fails the handshake instead of blocking it forever.
+const tokenCmdTimeout = 10 * time.Second
+
+// tokenCmdProvider runs an external command to obtain a JWT for
+// SASL/OAUTHBEARER, caching it until shortly before it expires.
+type tokenCmdProvider struct {
+	cmd string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func newTokenCmdProvider(cmd string) *tokenCmdProvider {
+	return &tokenCmdProvider{cmd: cmd}
+}
+
+// Token implements sarama.AccessTokenProvider
+func (p *tokenCmdProvider) Token() (*sarama.AccessToken, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token == "" || time.Now().After(p.expiry.Add(-tokenExpiryMargin)) {
+		token, expiry, err := runTokenCmd(p.cmd)
+		if err != nil {
+			return nil, err
+		}
+		p.token = token
+		p.expiry = expiry
+	}
+
+	return &sarama.AccessToken{Token: p.token}, nil
+}
+
+func runTokenCmd(cmd string) (string, time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCmdTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "sh", "-c", cmd).Output()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("sasl-token-cmd failed: %w", err)
+	}
+	token := strings.TrimSpace(string(out))
+
+	expiry, err := jwtExpiry(token)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("sasl-token-cmd produced an invalid JWT: %w", err)
+	}
+	return token, expiry, nil
+}
+
+// jwtExpiry decodes a JWT's exp claim without verifying its signature; the
+// broker is the one that validates it, this is only used to know when to refresh.
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("expected a 3-part JWT, got %d parts", len(parts))
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
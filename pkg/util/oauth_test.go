@@ -0,0 +1,53 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func buildJWT(t *testing.T, exp int64) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload, err := json.Marshal(struct {
+		Exp int64 `json:"exp"`
+	}{Exp: exp})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestJwtExpiry(t *testing.T) {
+	t.Run("valid token", func(t *testing.T) {
+		token := buildJWT(t, 1700000000)
+		expiry, err := jwtExpiry(token)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := time.Unix(1700000000, 0)
+		if !expiry.Equal(want) {
+			t.Fatalf("got %v, want %v", expiry, want)
+		}
+	})
+
+	t.Run("wrong number of parts", func(t *testing.T) {
+		if _, err := jwtExpiry("not-a-jwt"); err == nil {
+			t.Fatal("expected an error for a malformed JWT")
+		}
+	})
+
+	t.Run("invalid base64 payload", func(t *testing.T) {
+		if _, err := jwtExpiry("a.not base64.c"); err == nil {
+			t.Fatal("expected an error for an invalid base64 payload")
+		}
+	})
+
+	t.Run("invalid json payload", func(t *testing.T) {
+		payload := base64.RawURLEncoding.EncodeToString([]byte("not json"))
+		if _, err := jwtExpiry("a." + payload + ".c"); err == nil {
+			t.Fatal("expected an error for an invalid JSON payload")
+		}
+	})
+}
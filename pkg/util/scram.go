@@ -0,0 +1,47 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+
+	"github.com/xdg-go/scram"
+)
+
+var (
+	scramSHA256 scram.HashGeneratorFcn = func() hash.Hash { return sha256.New() }
+	scramSHA512 scram.HashGeneratorFcn = func() hash.Hash { return sha512.New() }
+)
+
+// xdgSCRAMClient adapts github.com/xdg-go/scram (pbkdf2-based) to sarama's
+// SCRAMClient interface.
+type xdgSCRAMClient struct {
+	*scram.Client
+	*scram.ClientConversation
+	scram.HashGeneratorFcn
+}
+
+func newXDGSCRAMClient(fcn scram.HashGeneratorFcn) *xdgSCRAMClient {
+	return &xdgSCRAMClient{HashGeneratorFcn: fcn}
+}
+
+// Begin implements sarama.SCRAMClient
+func (c *xdgSCRAMClient) Begin(userName, password, authzID string) error {
+	client, err := c.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.Client = client
+	c.ClientConversation = c.Client.NewConversation()
+	return nil
+}
+
+// Step implements sarama.SCRAMClient
+func (c *xdgSCRAMClient) Step(challenge string) (string, error) {
+	return c.ClientConversation.Step(challenge)
+}
+
+// Done implements sarama.SCRAMClient
+func (c *xdgSCRAMClient) Done() bool {
+	return c.ClientConversation.Done()
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+)
+
+// GetTLSConfiguration builds a *tls.Config from the given cacert/cert/key files.
+// It returns a nil config and enabled=false when no cacert is provided.
+func GetTLSConfiguration(cacertFile, certFile, keyFile string, insecure bool) (*tls.Config, bool, error) {
+	if cacertFile == "" {
+		return nil, false, nil
+	}
+
+	caCert, err := ioutil.ReadFile(cacertFile)
+	if err != nil {
+		return nil, false, err
+	}
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+
+	cfg := &tls.Config{
+		RootCAs:            caCertPool,
+		InsecureSkipVerify: insecure,
+	}
+
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, false, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, true, nil
+}